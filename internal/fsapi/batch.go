@@ -0,0 +1,39 @@
+package fsapi
+
+import (
+	"io/fs"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// BatchFS is an optional interface an FS may implement to perform many
+// operations with fewer syscalls than calling the single-path method
+// once per entry. Callers that don't need batching should keep using
+// the corresponding FS method: BatchFS exists for hot spots like
+// recursive directory walks, where each entry otherwise costs at least
+// an `openat`+`fstat`+`close`.
+//
+// # Notes
+//
+//   - Implementations are free to execute a batch however they like,
+//     including sequentially; the only contract is that len(results) ==
+//     len(paths) and results[i] corresponds to paths[i].
+//   - A path that fails does not abort the rest of the batch: its Errno
+//     is reported at the same index, and every other path is still
+//     attempted.
+type BatchFS interface {
+	FS
+
+	// OpenFileMany is like len(paths) calls to OpenFile, but may be
+	// implemented with fewer syscalls.
+	OpenFileMany(paths []string, flag int, perm fs.FileMode) ([]File, []experimentalsys.Errno)
+
+	// StatMany is like len(paths) calls to Stat, but may be implemented
+	// with fewer syscalls.
+	StatMany(paths []string) ([]sys.Stat_t, []experimentalsys.Errno)
+
+	// UnlinkMany is like len(paths) calls to Unlink, but may be
+	// implemented with fewer syscalls.
+	UnlinkMany(paths []string) []experimentalsys.Errno
+}
@@ -105,6 +105,27 @@ type FS interface {
 	//     it refers to.
 	Stat(path string) (sys.Stat_t, experimentalsys.Errno)
 
+	// Statfs gets file system status for the volume containing `path`.
+	//
+	// # Errors
+	//
+	// A zero Errno is success. The below are expected otherwise:
+	//   - ENOSYS: the implementation does not support this function.
+	//   - ENOENT: `path` doesn't exist.
+	//
+	// # Notes
+	//
+	//   - This is like syscall.Statfs, except the `path` is relative to
+	//     this file system, and the result is the WASI-oriented
+	//     sys.StatFs_t rather than a raw syscall.Statfs_t.
+	//   - This is like `statvfs` in POSIX. See
+	//     https://pubs.opengroup.org/onlinepubs/9699919799/functions/statvfs.html
+	//   - Implementations backed by something other than a real volume
+	//     (for example an in-memory FS) may return synthetic, but
+	//     self-consistent values instead of ENOSYS, so that guests doing
+	//     disk-usage or quota checks don't need special-case handling.
+	Statfs(path string) (sys.StatFs_t, experimentalsys.Errno)
+
 	// Mkdir makes a directory.
 	//
 	// # Errors
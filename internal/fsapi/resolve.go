@@ -0,0 +1,165 @@
+package fsapi
+
+import (
+	iofs "io/fs"
+	"strings"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// symloopMax bounds how many symlinks ResolvePath will follow while
+// resolving a single path, mirroring the POSIX SYMLOOP_MAX convention
+// (commonly 40). Without it, a symlink cycle would make ResolvePath
+// loop forever instead of failing with ELOOP.
+const symloopMax = 40
+
+// ResolvePath resolves `path` against `fs`, following symlinks up to
+// symloopMax times, and returns the canonical result: every Windows
+// `\` separator normalized to `/`, as the Readlink doc comment already
+// promises, and every "." and ".." component collapsed.
+//
+// When `followSymlinks` is false, a symlink at the very last path
+// component is left unresolved (matching Lstat); every symlink
+// encountered earlier in the path is always followed, since POSIX
+// directory components can never themselves be symlinks left
+// unresolved.
+//
+// This is the shared implementation WASI's `path_readlink` and a guest
+// `realpath()` both want: previously every fsapi.FS implementation had
+// to get ELOOP handling and separator normalization right on its own.
+//
+// # Errors
+//
+// A zero Errno is success. The below are expected otherwise:
+//   - ENOENT: a path component doesn't exist.
+//   - ENOTDIR: a non-final path component exists, but isn't a directory
+//     or a symlink to one.
+//   - ELOOP: more than symloopMax symlinks were followed.
+func ResolvePath(fs FS, path string, followSymlinks bool) (string, experimentalsys.Errno) {
+	path = normalizeSeparators(path)
+	absolute := strings.HasPrefix(path, "/")
+	components := splitPathComponents(path)
+
+	var resolved []string
+	followed := 0
+
+	for i := 0; i < len(components); i++ {
+		switch components[i] {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			continue
+		}
+
+		candidate := append(append([]string(nil), resolved...), components[i])
+		last := i == len(components)-1
+
+		st, errno := fs.Lstat(joinPathComponents(absolute, candidate))
+		if errno != 0 {
+			return "", errno
+		}
+
+		if st.Mode.Type() == iofs.ModeSymlink && (!last || followSymlinks) {
+			followed++
+			if followed > symloopMax {
+				return "", experimentalsys.ELOOP
+			}
+
+			target, errno := fs.Readlink(joinPathComponents(absolute, candidate))
+			if errno != 0 {
+				return "", errno
+			}
+			target = normalizeSeparators(target)
+			if strings.HasPrefix(target, "/") {
+				absolute = true
+				resolved = nil
+			}
+
+			rest := append(splitPathComponents(target), components[i+1:]...)
+			components = rest
+			i = -1
+			continue
+		}
+
+		resolved = candidate
+	}
+
+	return joinPathComponents(absolute, resolved), 0
+}
+
+// LstatChain returns the Lstat result of every successive prefix of
+// `path`, from its first component to its last, without following any
+// symlink found along the way. It stops at the first component that
+// doesn't exist (or otherwise fails), returning the chain gathered so
+// far alongside the error.
+//
+// This is the other half of realpath()-style diagnostics: ResolvePath
+// answers "what does this path resolve to", while LstatChain answers
+// "what, exactly, does the kernel see at each step getting there" -
+// useful for guests that need to detect a symlink loop themselves, or
+// report which component of a deep path failed.
+func LstatChain(fs FS, path string) ([]sys.Stat_t, experimentalsys.Errno) {
+	path = normalizeSeparators(path)
+	absolute := strings.HasPrefix(path, "/")
+	components := splitPathComponents(path)
+
+	var chain []sys.Stat_t
+	var prefix []string
+	for _, c := range components {
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if len(prefix) > 0 {
+				prefix = prefix[:len(prefix)-1]
+			}
+			if len(chain) > 0 {
+				chain = chain[:len(chain)-1]
+			}
+			continue
+		}
+
+		prefix = append(prefix, c)
+		st, errno := fs.Lstat(joinPathComponents(absolute, prefix))
+		if errno != 0 {
+			return chain, errno
+		}
+		chain = append(chain, st)
+	}
+	return chain, 0
+}
+
+// normalizeSeparators normalizes Windows `\` separators to `/`.
+func normalizeSeparators(path string) string {
+	return strings.ReplaceAll(path, `\`, "/")
+}
+
+// splitPathComponents splits `path` (already `/`-separated) into its
+// non-empty components, preserving "." and ".." so callers can apply
+// their own semantics for them.
+func splitPathComponents(path string) []string {
+	var components []string
+	for _, c := range strings.Split(path, "/") {
+		if c != "" {
+			components = append(components, c)
+		}
+	}
+	return components
+}
+
+// joinPathComponents is the inverse of splitPathComponents, re-adding a
+// leading "/" when the original path was absolute.
+func joinPathComponents(absolute bool, components []string) string {
+	joined := strings.Join(components, "/")
+	if absolute {
+		return "/" + joined
+	}
+	if joined == "" {
+		return "."
+	}
+	return joined
+}
@@ -0,0 +1,11 @@
+package fsapi
+
+import (
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Statfs implements FS.Statfs as ENOSYS.
+func (UnimplementedFS) Statfs(path string) (sys.StatFs_t, experimentalsys.Errno) {
+	return sys.StatFs_t{}, experimentalsys.ENOSYS
+}
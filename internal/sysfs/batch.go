@@ -0,0 +1,106 @@
+package sysfs
+
+import (
+	"io/fs"
+	"runtime"
+	"sync"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/fsapi"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// maxBatchWorkers bounds how many goroutines a single batch may use, so
+// that a directory with thousands of entries doesn't spawn thousands of
+// goroutines.
+const maxBatchWorkers = 8
+
+// NewBatchFS adapts `fs` into an fsapi.BatchFS. If `fs` already
+// implements fsapi.BatchFS (for example, an io_uring backed
+// implementation on Linux), it is returned as-is; otherwise it is
+// wrapped in a portable implementation that fans a batch out across a
+// bounded pool of goroutines.
+func NewBatchFS(fs fsapi.FS) fsapi.BatchFS {
+	if b, ok := fs.(fsapi.BatchFS); ok {
+		return b
+	}
+	return newPlatformBatchFS(fs)
+}
+
+// goroutinePoolBatchFS implements fsapi.BatchFS for any fsapi.FS by
+// running each path in the batch on a bounded pool of goroutines. It is
+// the fallback used everywhere a platform-specific, lower-overhead
+// implementation (such as io_uring on Linux) isn't available.
+type goroutinePoolBatchFS struct {
+	fsapi.FS
+}
+
+// OpenFileMany implements fsapi.BatchFS.
+func (b *goroutinePoolBatchFS) OpenFileMany(paths []string, flag int, perm fs.FileMode) ([]fsapi.File, []experimentalsys.Errno) {
+	files := make([]fsapi.File, len(paths))
+	errnos := make([]experimentalsys.Errno, len(paths))
+	runBatch(len(paths), func(i int) {
+		files[i], errnos[i] = b.FS.OpenFile(paths[i], flag, perm)
+	})
+	return files, errnos
+}
+
+// StatMany implements fsapi.BatchFS.
+func (b *goroutinePoolBatchFS) StatMany(paths []string) ([]sys.Stat_t, []experimentalsys.Errno) {
+	stats := make([]sys.Stat_t, len(paths))
+	errnos := make([]experimentalsys.Errno, len(paths))
+	runBatch(len(paths), func(i int) {
+		stats[i], errnos[i] = b.FS.Stat(paths[i])
+	})
+	return stats, errnos
+}
+
+// UnlinkMany implements fsapi.BatchFS.
+func (b *goroutinePoolBatchFS) UnlinkMany(paths []string) []experimentalsys.Errno {
+	errnos := make([]experimentalsys.Errno, len(paths))
+	runBatch(len(paths), func(i int) {
+		errnos[i] = b.FS.Unlink(paths[i])
+	})
+	return errnos
+}
+
+// runBatch calls fn(i) for every i in [0, n), spread across a pool of
+// at most maxBatchWorkers goroutines (fewer if n or GOMAXPROCS is
+// smaller), and blocks until all calls have returned.
+func runBatch(n int, fn func(i int)) {
+	if n == 0 {
+		return
+	}
+
+	workers := maxBatchWorkers
+	if gm := runtime.GOMAXPROCS(0); gm < workers {
+		workers = gm
+	}
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	indices := make(chan int, n)
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
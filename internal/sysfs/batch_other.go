@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sysfs
+
+import "github.com/tetratelabs/wazero/internal/fsapi"
+
+// newPlatformBatchFS falls back to the portable goroutine-pool
+// implementation everywhere except Linux, which has an io_uring backed
+// implementation in batch_linux.go.
+func newPlatformBatchFS(fs fsapi.FS) fsapi.BatchFS {
+	return &goroutinePoolBatchFS{FS: fs}
+}
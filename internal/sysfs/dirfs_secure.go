@@ -0,0 +1,498 @@
+//go:build linux || darwin
+
+package sysfs
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/fsapi"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// maxSymlinkChainLength bounds the number of symlinks resolved while
+// walking a single path, mirroring the POSIX SYMLOOP_MAX convention
+// (commonly 40), so that a cyclical or adversarial symlink chain fails
+// fast with ELOOP rather than hanging or overflowing the stack.
+const maxSymlinkChainLength = 40
+
+// NewDirFSSecure is like NewDirFS, except every path is resolved one
+// component at a time relative to an open directory file descriptor,
+// using openat, fstatat, unlinkat, linkat, symlinkat, renameat and
+// mkdirat, instead of joining `path` onto `dir` and handing the
+// resulting string to the kernel in one shot.
+//
+// This closes a symlink race (TOCTOU) that the naive join-then-open
+// approach is vulnerable to: nothing checks that a symlink swapped in
+// between resolving a parent directory and opening the final component
+// doesn't redirect the operation above `dir`. Because dirFSSecure never
+// builds an absolute path and never lets the kernel re-resolve a ".."
+// that could walk past the root, a symlink can only ever be followed to
+// somewhere inside the sandboxed tree.
+//
+// # Notes
+//
+//   - This implementation requires *at syscalls, so it is only built on
+//     Linux and Darwin.
+//   - On unsupported platforms, prefer NewDirFS, which is not immune to
+//     symlink races but works everywhere.
+func NewDirFSSecure(dir string) (fsapi.FS, error) {
+	root, err := unix.Open(dir, unix.O_DIRECTORY|unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &dirFSSecure{root: root, path: dir}, nil
+}
+
+// dirFSSecure implements fsapi.FS by resolving paths relative to an
+// open directory file descriptor, rather than a string path.
+type dirFSSecure struct {
+	fsapi.UnimplementedFS
+
+	// root is a file descriptor for the sandboxed directory, opened
+	// once in NewDirFSSecure and held for the lifetime of this FS.
+	root int
+
+	// path is the original directory NewDirFSSecure was given. It is
+	// only used for diagnostics: every actual operation is resolved
+	// relative to root, never by re-joining path.
+	path string
+}
+
+// String implements fmt.Stringer.
+func (d *dirFSSecure) String() string {
+	return d.path
+}
+
+// resolve walks every component of `path` relative to d.root, following
+// symlinks it encounters along the way - including, when followFinal is
+// true, a symlink at the very last component - up to
+// maxSymlinkChainLength times. A leading "/" is treated as relative to
+// the root, and a ".." that would walk above the root is clamped to the
+// root instead of escaping it; an interior ".." pops back to the actual
+// parent directory opened for the component before it.
+//
+// On success, it returns a file descriptor for the parent directory
+// (which the caller must close unless it is d.root) and the final path
+// component, ready to be used in a *at syscall such as
+// unix.Unlinkat(parent, base, 0). If the final component doesn't exist,
+// resolve still succeeds, returning it as-is: it's up to the caller's own
+// syscall to decide whether that's an error (most operations) or exactly
+// what was wanted (OpenFile with O_CREAT).
+//
+// When followFinal is false, the final component is returned as-is,
+// even if it names a symlink: the caller is expected to pass
+// O_NOFOLLOW/AT_SYMLINK_NOFOLLOW so the kernel operates on the link
+// itself. When followFinal is true, resolve follows a symlink at the
+// final component itself the same way it follows one in the middle of
+// the path, so a *at syscall made against the returned (parent, base)
+// can't be redirected by the kernel re-resolving a symlink whose target
+// reaches outside the sandbox - the defining bug this type exists to
+// close. It classifies the final component with fstatat rather than by
+// opening it: opening it - even O_RDONLY, just to probe - has effects a
+// caller never asked for, such as blocking on a FIFO with no writer yet,
+// failing with ENXIO on a UNIX socket, or failing with EACCES on a
+// write-only regular file that the caller's real, different open mode
+// would have succeeded on.
+func (d *dirFSSecure) resolve(path string, followFinal bool) (parent int, base string, errno experimentalsys.Errno) {
+	// stack holds every directory fd opened while walking `path`, from
+	// d.root (stack[0], never closed here) down to the directory the
+	// next component will be opened relative to. Keeping the whole
+	// chain open - rather than closing each as soon as we descend past
+	// it - is what lets an interior ".." pop back to its real parent
+	// instead of being treated as a no-op.
+	stack := []int{d.root}
+	closeAllBut := func(keep int) {
+		for _, fd := range stack {
+			if fd != d.root && fd != keep {
+				_ = unix.Close(fd)
+			}
+		}
+	}
+
+	components := splitComponents(path)
+	symlinksFollowed := 0
+
+	for i := 0; i < len(components); i++ {
+		component := components[i]
+		switch component {
+		case ".", "":
+			continue
+		case "..":
+			if len(stack) > 1 {
+				_ = unix.Close(stack[len(stack)-1])
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		cur := stack[len(stack)-1]
+		last := i == len(components)-1
+
+		if last && !followFinal {
+			closeAllBut(cur)
+			return cur, component, 0
+		}
+
+		if last {
+			// followFinal: classify the component without opening it.
+			var st unix.Stat_t
+			err := unix.Fstatat(cur, component, &st, unix.AT_SYMLINK_NOFOLLOW)
+			switch {
+			case err == unix.ENOENT:
+				// Doesn't exist yet: the caller's own syscall (e.g.
+				// OpenFile with O_CREAT) is the one that gets to decide
+				// whether that's an error.
+				closeAllBut(cur)
+				return cur, component, 0
+			case err != nil:
+				closeAllBut(-1)
+				return -1, "", experimentalsys.UnwrapOSError(err)
+			}
+			if st.Mode&unix.S_IFMT != unix.S_IFLNK {
+				closeAllBut(cur)
+				return cur, component, 0
+			}
+			target, rerr := readlinkat(cur, component)
+			if rerr != 0 {
+				closeAllBut(-1)
+				return -1, "", rerr
+			}
+			symlinksFollowed++
+			if symlinksFollowed > maxSymlinkChainLength {
+				closeAllBut(-1)
+				return -1, "", experimentalsys.ELOOP
+			}
+			if strings.HasPrefix(target, "/") {
+				// An absolute target is relative to the sandbox root,
+				// never to wherever cur happens to be.
+				closeAllBut(-1)
+				stack = []int{d.root}
+			}
+			components = append(splitComponents(target), components[i+1:]...)
+			i = -1
+			continue
+		}
+
+		// Not last: an intermediate component must actually be opened
+		// as a directory fd to keep walking relative to it.
+		next, err := unix.Openat(cur, component, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		switch {
+		case err == unix.ELOOP:
+			// The component is a symlink: resolve it and splice the
+			// target into the remaining components.
+			target, rerr := readlinkat(cur, component)
+			if rerr != 0 {
+				closeAllBut(-1)
+				return -1, "", rerr
+			}
+			symlinksFollowed++
+			if symlinksFollowed > maxSymlinkChainLength {
+				closeAllBut(-1)
+				return -1, "", experimentalsys.ELOOP
+			}
+			if strings.HasPrefix(target, "/") {
+				closeAllBut(-1)
+				stack = []int{d.root}
+			}
+			components = append(splitComponents(target), components[i+1:]...)
+			i = -1
+			continue
+		case err == unix.ENOTDIR:
+			closeAllBut(-1)
+			return -1, "", experimentalsys.ENOTDIR
+		case err != nil:
+			closeAllBut(-1)
+			return -1, "", experimentalsys.UnwrapOSError(err)
+		}
+		stack = append(stack, next)
+	}
+	return stack[len(stack)-1], ".", 0
+}
+
+// OpenFile implements the same method on fsapi.FS.
+//
+// Unlike openFile, it honors a caller-supplied O_NOFOLLOW instead of
+// erasing it: when set, the final component is left unresolved and
+// handed to the kernel with O_NOFOLLOW so it fails rather than follows
+// a symlink. When unset (the common case of opening a regular file),
+// resolve itself follows a final symlink - walking its target back
+// through the sandbox root the same as any other component - and the
+// real Openat below still adds O_NOFOLLOW, so a symlink swapped in
+// between the two can only make the call fail, never escape.
+func (d *dirFSSecure) OpenFile(path string, flag int, perm fs.FileMode) (fsapi.File, experimentalsys.Errno) {
+	followFinal := flag&O_NOFOLLOW == 0
+	parent, base, errno := d.resolve(path, followFinal)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	realFlag := flag
+	if followFinal {
+		realFlag |= unix.O_NOFOLLOW
+	}
+	fd, err := unix.Openat(parent, base, realFlag|unix.O_CLOEXEC, uint32(perm))
+	if err != nil {
+		return nil, experimentalsys.UnwrapOSError(err)
+	}
+	return newOsFile(d.path+"/"+path, flag, perm, os.NewFile(uintptr(fd), base)), 0
+}
+
+// Lstat implements the same method on fsapi.FS.
+func (d *dirFSSecure) Lstat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	return d.stat(path, false)
+}
+
+// Stat implements the same method on fsapi.FS.
+func (d *dirFSSecure) Stat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	return d.stat(path, true)
+}
+
+// stat resolves path and fstatats it. When followSymlinks is true, any
+// symlink at the final component is followed by resolve itself (never
+// by the kernel re-resolving a raw target string), so the final
+// Fstatat below is always made with AT_SYMLINK_NOFOLLOW: by this point
+// base is already confirmed not to be a symlink, and this way a symlink
+// raced back in after resolve runs can only fail the call, not divert
+// it outside the sandbox.
+func (d *dirFSSecure) stat(path string, followSymlinks bool) (sys.Stat_t, experimentalsys.Errno) {
+	parent, base, errno := d.resolve(path, followSymlinks)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	var st unix.Stat_t
+	if err := unix.Fstatat(parent, base, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return sys.Stat_t{}, experimentalsys.UnwrapOSError(err)
+	}
+	return statFromUnix(st), 0
+}
+
+// Statfs implements the same method on fsapi.FS.
+func (d *dirFSSecure) Statfs(path string) (sys.StatFs_t, experimentalsys.Errno) {
+	parent, base, errno := d.resolve(path, true)
+	if errno != 0 {
+		return sys.StatFs_t{}, errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	fd, err := unix.Openat(parent, base, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return sys.StatFs_t{}, experimentalsys.UnwrapOSError(err)
+	}
+	defer unix.Close(fd)
+
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(fd, &st); err != nil {
+		return sys.StatFs_t{}, experimentalsys.UnwrapOSError(err)
+	}
+	return statFsFromUnix(st), 0
+}
+
+// Mkdir implements the same method on fsapi.FS.
+func (d *dirFSSecure) Mkdir(path string, perm fs.FileMode) experimentalsys.Errno {
+	parent, base, errno := d.resolve(path, false)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	if err := unix.Mkdirat(parent, base, uint32(perm)); err != nil {
+		return experimentalsys.UnwrapOSError(err)
+	}
+	return 0
+}
+
+// Chmod implements the same method on fsapi.FS.
+//
+// Like Stat, it resolves a final symlink itself via resolve rather than
+// letting Fchmodat re-resolve the raw target string, so chmod can't be
+// redirected outside the sandbox by a symlink.
+func (d *dirFSSecure) Chmod(path string, perm fs.FileMode) experimentalsys.Errno {
+	parent, base, errno := d.resolve(path, true)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	if err := unix.Fchmodat(parent, base, uint32(perm), 0); err != nil {
+		return experimentalsys.UnwrapOSError(err)
+	}
+	return 0
+}
+
+// Rename implements the same method on fsapi.FS.
+func (d *dirFSSecure) Rename(from, to string) experimentalsys.Errno {
+	fromParent, fromBase, errno := d.resolve(from, false)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(fromParent)
+
+	toParent, toBase, errno := d.resolve(to, false)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(toParent)
+
+	if err := unix.Renameat(fromParent, fromBase, toParent, toBase); err != nil {
+		return experimentalsys.UnwrapOSError(err)
+	}
+	return 0
+}
+
+// Rmdir implements the same method on fsapi.FS.
+func (d *dirFSSecure) Rmdir(path string) experimentalsys.Errno {
+	parent, base, errno := d.resolve(path, false)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	if err := unix.Unlinkat(parent, base, unix.AT_REMOVEDIR); err != nil {
+		return experimentalsys.UnwrapOSError(err)
+	}
+	return 0
+}
+
+// Unlink implements the same method on fsapi.FS.
+func (d *dirFSSecure) Unlink(path string) experimentalsys.Errno {
+	parent, base, errno := d.resolve(path, false)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	if err := unix.Unlinkat(parent, base, 0); err != nil {
+		return experimentalsys.UnwrapOSError(err)
+	}
+	return 0
+}
+
+// Link implements the same method on fsapi.FS.
+func (d *dirFSSecure) Link(oldPath, newPath string) experimentalsys.Errno {
+	oldParent, oldBase, errno := d.resolve(oldPath, false)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(oldParent)
+
+	newParent, newBase, errno := d.resolve(newPath, false)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(newParent)
+
+	if err := unix.Linkat(oldParent, oldBase, newParent, newBase, 0); err != nil {
+		return experimentalsys.UnwrapOSError(err)
+	}
+	return 0
+}
+
+// Symlink implements the same method on fsapi.FS.
+func (d *dirFSSecure) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	parent, base, errno := d.resolve(linkName, false)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	if err := unix.Symlinkat(oldPath, parent, base); err != nil {
+		return experimentalsys.UnwrapOSError(err)
+	}
+	return 0
+}
+
+// Readlink implements the same method on fsapi.FS.
+func (d *dirFSSecure) Readlink(path string) (string, experimentalsys.Errno) {
+	parent, base, errno := d.resolve(path, false)
+	if errno != 0 {
+		return "", errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	return readlinkat(parent, base)
+}
+
+// Utimens implements the same method on fsapi.FS.
+//
+// Like Stat and Chmod, a symlinkFollow final component is resolved by
+// resolve itself rather than by UtimesNanoAt re-resolving the raw
+// target, so the real syscall below is always made with
+// AT_SYMLINK_NOFOLLOW.
+func (d *dirFSSecure) Utimens(path string, times *[2]syscall.Timespec, symlinkFollow bool) experimentalsys.Errno {
+	parent, base, errno := d.resolve(path, symlinkFollow)
+	if errno != 0 {
+		return errno
+	}
+	defer d.closeIfNotRoot(parent)
+
+	ts := [2]unix.Timespec{}
+	if times != nil {
+		ts[0] = unix.Timespec{Sec: times[0].Sec, Nsec: times[0].Nsec}
+		ts[1] = unix.Timespec{Sec: times[1].Sec, Nsec: times[1].Nsec}
+	} else {
+		ts[0].Nsec, ts[1].Nsec = unix.UTIME_NOW, unix.UTIME_NOW
+	}
+	if err := unix.UtimesNanoAt(parent, base, ts[:], unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return experimentalsys.UnwrapOSError(err)
+	}
+	return 0
+}
+
+// ResolveForBatch exposes resolve to fsapi.BatchFS implementations (see
+// batch_linux.go) that want to submit a batch of *at requests against
+// this FS's sandbox root instead of a bare path against AT_FDCWD, which
+// would otherwise ignore the sandboxing entirely and let the kernel
+// re-resolve symlinks and ".." outside of it. The returned parent must
+// be closed by the caller unless it is the root fd this FS was opened
+// with.
+func (d *dirFSSecure) ResolveForBatch(path string, followFinal bool) (parent int, base string, errno experimentalsys.Errno) {
+	return d.resolve(path, followFinal)
+}
+
+// IsRootFd reports whether fd is this FS's own root descriptor, which
+// BatchFS implementations must never close.
+func (d *dirFSSecure) IsRootFd(fd int) bool {
+	return fd == d.root
+}
+
+func (d *dirFSSecure) closeIfNotRoot(fd int) {
+	if fd != d.root {
+		_ = unix.Close(fd)
+	}
+}
+
+// splitComponents splits `p` into its non-empty, non-"." path
+// components. A leading "/" is treated as relative to the sandbox root,
+// not the real filesystem root.
+func splitComponents(p string) []string {
+	var components []string
+	for _, c := range strings.Split(p, "/") {
+		if c != "" && c != "." {
+			components = append(components, c)
+		}
+	}
+	return components
+}
+
+func readlinkat(dirfd int, path string) (string, experimentalsys.Errno) {
+	buf := make([]byte, 256)
+	for {
+		n, err := unix.Readlinkat(dirfd, path, buf)
+		if err != nil {
+			return "", experimentalsys.UnwrapOSError(err)
+		}
+		if n < len(buf) {
+			return string(buf[:n]), 0
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package sysfs
+
+import (
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/fsapi"
+)
+
+// NewDirFSSecure is only implemented on operating systems with *at
+// syscalls (openat, fstatat, etc.), currently Linux and Darwin.
+//
+// On Windows, an equivalent could be built on handle-relative APIs such
+// as NtCreateFile, but that isn't implemented yet. Use NewDirFS there
+// instead, keeping in mind it doesn't resist symlink races.
+func NewDirFSSecure(dir string) (fsapi.FS, error) {
+	return nil, experimentalsys.ENOSYS
+}
@@ -0,0 +1,227 @@
+package sysfs
+
+import (
+	"io/fs"
+	"sync"
+	"syscall"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/fsapi"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// newMemFile returns a fsapi.File view over `node`, honoring `flag`'s
+// access mode and append bit the same way an *os.File opened against a
+// real file would. `mu` is the owning MemFS's mutex: memFile takes it
+// around every access to `node`'s shared state, since two handles to
+// the same node (via Link, or two concurrent opens of the same path)
+// can otherwise race on it.
+func newMemFile(mu *sync.Mutex, node *memInode, flag int) fsapi.File {
+	return &memFile{mu: mu, node: node, flag: flag}
+}
+
+// memFile is the open-file handle fsapi.File counterpart to a memInode:
+// several memFile instances (each with their own offset) may be open
+// against the same memInode at once, matching POSIX semantics.
+type memFile struct {
+	fsapi.UnimplementedFile
+
+	mu     *sync.Mutex
+	node   *memInode
+	flag   int
+	offset int64
+
+	// readdirSnapshot snapshots node.children the first time Readdir is
+	// called on a directory handle, so that the cookie it hands back
+	// (an index into this slice) stays stable even if the directory is
+	// mutated through a different handle mid-walk.
+	readdirSnapshot []memDirent
+}
+
+func (f *memFile) readable() bool { return f.flag&syscall.O_WRONLY == 0 }
+func (f *memFile) writable() bool { return f.flag&(syscall.O_WRONLY|syscall.O_RDWR) != 0 }
+
+// Stat implements fsapi.File.
+func (f *memFile) Stat() (sys.Stat_t, experimentalsys.Errno) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.node.statT(), 0
+}
+
+// IsDir implements fsapi.File.
+func (f *memFile) IsDir() (bool, experimentalsys.Errno) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.node.mode.IsDir(), 0
+}
+
+// Read implements fsapi.File.
+func (f *memFile) Read(buf []byte) (int, experimentalsys.Errno) {
+	if !f.readable() {
+		return 0, experimentalsys.EBADF
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.node.mode.IsDir() {
+		return 0, experimentalsys.EISDIR
+	}
+	n, errno := f.pread(buf, f.offset)
+	f.offset += int64(n)
+	return n, errno
+}
+
+// Pread implements fsapi.File.
+func (f *memFile) Pread(buf []byte, offset int64) (int, experimentalsys.Errno) {
+	if !f.readable() {
+		return 0, experimentalsys.EBADF
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pread(buf, offset)
+}
+
+// pread reads from node.data; callers must hold f.mu.
+func (f *memFile) pread(buf []byte, offset int64) (int, experimentalsys.Errno) {
+	if offset >= int64(len(f.node.data)) {
+		return 0, 0 // EOF is reported as a zero-length, zero-errno read.
+	}
+	n := copy(buf, f.node.data[offset:])
+	return n, 0
+}
+
+// Write implements fsapi.File.
+func (f *memFile) Write(buf []byte) (int, experimentalsys.Errno) {
+	if !f.writable() {
+		return 0, experimentalsys.EBADF
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.flag&syscall.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	n, errno := f.pwrite(buf, f.offset)
+	f.offset += int64(n)
+	return n, errno
+}
+
+// Pwrite implements fsapi.File.
+func (f *memFile) Pwrite(buf []byte, offset int64) (int, experimentalsys.Errno) {
+	if !f.writable() {
+		return 0, experimentalsys.EBADF
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pwrite(buf, offset)
+}
+
+// pwrite writes into node.data, growing it if necessary; callers must
+// hold f.mu.
+func (f *memFile) pwrite(buf []byte, offset int64) (int, experimentalsys.Errno) {
+	end := offset + int64(len(buf))
+	if end > int64(len(f.node.data)) {
+		grown := make([]byte, end)
+		copy(grown, f.node.data)
+		f.node.data = grown
+	}
+	n := copy(f.node.data[offset:end], buf)
+	f.node.mtim = 0
+	return n, 0
+}
+
+// Seek implements fsapi.File.
+func (f *memFile) Seek(offset int64, whence int) (int64, experimentalsys.Errno) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case 0: // io.SeekStart
+	case 1: // io.SeekCurrent
+		offset += f.offset
+	case 2: // io.SeekEnd
+		offset += int64(len(f.node.data))
+	default:
+		return 0, experimentalsys.EINVAL
+	}
+	if offset < 0 {
+		return 0, experimentalsys.EINVAL
+	}
+	f.offset = offset
+	return offset, 0
+}
+
+// Truncate implements fsapi.File.
+func (f *memFile) Truncate(size int64) experimentalsys.Errno {
+	if !f.writable() {
+		return experimentalsys.EBADF
+	}
+	if size < 0 {
+		return experimentalsys.EINVAL
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case size == int64(len(f.node.data)):
+	case size < int64(len(f.node.data)):
+		f.node.data = f.node.data[:size]
+	default:
+		grown := make([]byte, size)
+		copy(grown, f.node.data)
+		f.node.data = grown
+	}
+	return 0
+}
+
+// Sync implements fsapi.File.
+func (f *memFile) Sync() experimentalsys.Errno { return 0 }
+
+// Datasync implements fsapi.File.
+func (f *memFile) Datasync() experimentalsys.Errno { return 0 }
+
+// Chmod implements fsapi.File.
+func (f *memFile) Chmod(mode fs.FileMode) experimentalsys.Errno {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.node.mode = f.node.mode.Type() | mode.Perm()
+	return 0
+}
+
+// Readdir implements fsapi.File, returning entries in the directory's
+// insertion order. The cookie WASI's fd_readdir threads through is the
+// index into the snapshot taken on the first call, so concurrent
+// mutation of the directory through another handle can't shift
+// already-returned entries out from under an in-progress walk.
+func (f *memFile) Readdir(n int) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.node.mode.IsDir() {
+		return nil, experimentalsys.ENOTDIR
+	}
+	if f.readdirSnapshot == nil {
+		f.readdirSnapshot = append([]memDirent(nil), f.node.children...)
+	}
+
+	start := int(f.offset)
+	if start > len(f.readdirSnapshot) {
+		start = len(f.readdirSnapshot)
+	}
+	end := len(f.readdirSnapshot)
+	if n >= 0 && start+n < end {
+		end = start + n
+	}
+
+	dirents := make([]experimentalsys.Dirent, 0, end-start)
+	for i := start; i < end; i++ {
+		child := f.readdirSnapshot[i]
+		dirents = append(dirents, experimentalsys.Dirent{
+			Name: child.name,
+			Ino:  0,
+			Type: child.inode.mode.Type(),
+		})
+	}
+	f.offset = int64(end)
+	return dirents, 0
+}
+
+// Close implements fsapi.File.
+func (f *memFile) Close() experimentalsys.Errno {
+	return 0
+}
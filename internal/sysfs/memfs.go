@@ -0,0 +1,425 @@
+package sysfs
+
+import (
+	"io/fs"
+	"sync"
+	"syscall"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/fsapi"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// utimeNow and utimeOmit mirror Linux's UTIME_NOW/UTIME_OMIT special
+// values for syscall.Timespec.Nsec, which Utimens on every fsapi.FS
+// implementation is expected to honor regardless of GOOS.
+const (
+	utimeNow  = (1 << 30) - 1
+	utimeOmit = (1 << 30) - 2
+)
+
+// NewMemFS returns a *MemFS, an fsapi.FS implementation backed entirely
+// by Go data structures: no file ever touches disk. Unlike
+// Adapt(fs.FS), it is writable and supports symlinks and hardlinks,
+// making it suitable for deterministic WASI tests, snapshot/restore
+// fixtures, and short-lived environments (such as FaaS) where disk I/O
+// is undesirable or unavailable.
+func NewMemFS() *MemFS {
+	now := int64(0)
+	root := &memInode{mode: fs.ModeDir | 0o755, nlink: 1, atim: now, mtim: now, ctim: now}
+	return &MemFS{root: root}
+}
+
+// MemFS implements fsapi.FS entirely in memory. All operations take
+// mu, so concurrent callers are serialized; this is simple and correct,
+// though not as scalable as a real filesystem - acceptable for its
+// intended use as a test and fixture double.
+type MemFS struct {
+	fsapi.UnimplementedFS
+
+	mu   sync.Mutex
+	root *memInode
+}
+
+// memInode is a file, directory or symlink. Two directory entries
+// sharing the same *memInode (via Link) are hardlinks to each other:
+// they share content and metadata, and the inode is only collected once
+// nlink drops to zero.
+type memInode struct {
+	mode             fs.FileMode
+	nlink            int
+	atim, mtim, ctim int64
+
+	data     []byte       // regular files
+	symlink  string       // symlinks
+	children []memDirent  // directories, in insertion order
+}
+
+// memDirent is one entry of a directory, in the order it was created.
+// Insertion order, rather than name order, is what makes directory
+// listing cookies (an index into this slice) stable across Readdir
+// calls on the same open directory, matching what WASI's fd_readdir
+// cookie contract expects.
+type memDirent struct {
+	name  string
+	inode *memInode
+}
+
+func (n *memInode) indexOf(name string) int {
+	for i := range n.children {
+		if n.children[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (n *memInode) statT() sys.Stat_t {
+	size := int64(len(n.data))
+	if n.mode.IsDir() {
+		size = int64(len(n.children))
+	}
+	return sys.Stat_t{
+		Ino:   0,
+		Mode:  n.mode,
+		Nlink: uint64(n.nlink),
+		Size:  size,
+		Atim:  n.atim,
+		Mtim:  n.mtim,
+		Ctim:  n.ctim,
+	}
+}
+
+// walk resolves `path` against the tree rooted at m.root, following
+// symlinks for every component except optionally the last one.
+//
+// It returns the parent directory inode, the final path component
+// (ready to look up or create in parent), and the resolved inode if one
+// already exists at that path (nil otherwise).
+func (m *MemFS) walk(path string, followFinal bool) (parent *memInode, base string, node *memInode, errno experimentalsys.Errno) {
+	components := splitComponents(path)
+	cur := m.root
+	symlinksFollowed := 0
+
+	for i := 0; i < len(components); i++ {
+		name := components[i]
+		last := i == len(components)-1
+
+		if !cur.mode.IsDir() {
+			return nil, "", nil, experimentalsys.ENOTDIR
+		}
+		idx := cur.indexOf(name)
+		if idx < 0 {
+			if last {
+				return cur, name, nil, 0
+			}
+			return nil, "", nil, experimentalsys.ENOENT
+		}
+		child := cur.children[idx].inode
+
+		if child.mode.Type() == fs.ModeSymlink && (!last || followFinal) {
+			symlinksFollowed++
+			if symlinksFollowed > maxSymlinkChainLength {
+				return nil, "", nil, experimentalsys.ELOOP
+			}
+			rest := append(splitComponents(child.symlink), components[i+1:]...)
+			components = rest
+			if len(child.symlink) > 0 && child.symlink[0] == '/' {
+				cur = m.root
+			}
+			i = -1
+			continue
+		}
+
+		if last {
+			return cur, name, child, 0
+		}
+		cur = child
+	}
+	// path was "" or "."/"/": refers to the root itself.
+	return nil, "", m.root, 0
+}
+
+// OpenFile implements fsapi.FS.
+func (m *MemFS) OpenFile(path string, flag int, perm fs.FileMode) (fsapi.File, experimentalsys.Errno) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	parent, base, node, errno := m.walk(path, true)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	if node == nil {
+		if flag&syscall.O_CREAT == 0 {
+			return nil, experimentalsys.ENOENT
+		}
+		now := int64(0)
+		node = &memInode{mode: perm.Perm(), nlink: 1, atim: now, mtim: now, ctim: now}
+		parent.children = append(parent.children, memDirent{name: base, inode: node})
+	} else if flag&syscall.O_EXCL != 0 && flag&syscall.O_CREAT != 0 {
+		return nil, experimentalsys.EEXIST
+	} else if node.mode.IsDir() && flag&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, experimentalsys.EISDIR
+	} else if flag&syscall.O_TRUNC != 0 {
+		node.data = nil
+	}
+
+	return newMemFile(&m.mu, node, flag), 0
+}
+
+// Lstat implements fsapi.FS.
+func (m *MemFS) Lstat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _, node, errno := m.walk(path, false)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	if node == nil {
+		return sys.Stat_t{}, experimentalsys.ENOENT
+	}
+	return node.statT(), 0
+}
+
+// Stat implements fsapi.FS.
+func (m *MemFS) Stat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _, node, errno := m.walk(path, true)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	if node == nil {
+		return sys.Stat_t{}, experimentalsys.ENOENT
+	}
+	return node.statT(), 0
+}
+
+// Statfs implements fsapi.FS with synthetic, but self-consistent
+// numbers: there's no real volume backing a MemFS.
+func (m *MemFS) Statfs(string) (sys.StatFs_t, experimentalsys.Errno) {
+	const bsize = 4096
+	const blocks = 1 << 20
+	return sys.StatFs_t{
+		Bsize:  bsize,
+		Blocks: blocks,
+		Bfree:  blocks,
+		Bavail: blocks,
+		Files:  1 << 20,
+		Ffree:  1 << 20,
+		Fstype: "memfs",
+	}, 0
+}
+
+// Mkdir implements fsapi.FS.
+func (m *MemFS) Mkdir(path string, perm fs.FileMode) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent, base, node, errno := m.walk(path, true)
+	if errno != 0 {
+		return errno
+	}
+	if node != nil {
+		return experimentalsys.EEXIST
+	}
+	now := int64(0)
+	dir := &memInode{mode: fs.ModeDir | perm.Perm(), nlink: 1, atim: now, mtim: now, ctim: now}
+	parent.children = append(parent.children, memDirent{name: base, inode: dir})
+	return 0
+}
+
+// Chmod implements fsapi.FS.
+func (m *MemFS) Chmod(path string, perm fs.FileMode) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _, node, errno := m.walk(path, true)
+	if errno != 0 {
+		return errno
+	}
+	if node == nil {
+		return experimentalsys.ENOENT
+	}
+	node.mode = node.mode.Type() | perm.Perm()
+	return 0
+}
+
+// Rename implements fsapi.FS.
+func (m *MemFS) Rename(from, to string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fromParent, fromBase, fromNode, errno := m.walk(from, false)
+	if errno != 0 {
+		return errno
+	}
+	if fromNode == nil {
+		return experimentalsys.ENOENT
+	}
+
+	toParent, toBase, toNode, errno := m.walk(to, false)
+	if errno != 0 {
+		return errno
+	}
+	if toNode == fromNode {
+		// from and to name the same directory entry (e.g. from == to,
+		// or two different paths to the same hardlink): POSIX defines
+		// this as a no-op success. Falling through would have the
+		// code below remove toParent's entry for it, then try to
+		// find that now-gone entry again in fromParent by name.
+		return 0
+	}
+	if toNode != nil {
+		if toNode.mode.IsDir() && !fromNode.mode.IsDir() {
+			return experimentalsys.EISDIR
+		}
+		if !toNode.mode.IsDir() && fromNode.mode.IsDir() {
+			return experimentalsys.ENOTDIR
+		}
+		if toNode.mode.IsDir() && len(toNode.children) > 0 {
+			return experimentalsys.ENOTEMPTY
+		}
+		toParent.children = append(toParent.children[:toParent.indexOf(toBase)], toParent.children[toParent.indexOf(toBase)+1:]...)
+	}
+
+	idx := fromParent.indexOf(fromBase)
+	fromParent.children = append(fromParent.children[:idx], fromParent.children[idx+1:]...)
+	toParent.children = append(toParent.children, memDirent{name: toBase, inode: fromNode})
+	return 0
+}
+
+// Rmdir implements fsapi.FS.
+func (m *MemFS) Rmdir(path string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent, base, node, errno := m.walk(path, false)
+	if errno != 0 {
+		return errno
+	}
+	if node == nil {
+		return experimentalsys.ENOENT
+	}
+	if !node.mode.IsDir() {
+		return experimentalsys.ENOTDIR
+	}
+	if len(node.children) > 0 {
+		return experimentalsys.ENOTEMPTY
+	}
+	idx := parent.indexOf(base)
+	parent.children = append(parent.children[:idx], parent.children[idx+1:]...)
+	return 0
+}
+
+// Unlink implements fsapi.FS.
+func (m *MemFS) Unlink(path string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent, base, node, errno := m.walk(path, false)
+	if errno != 0 {
+		return errno
+	}
+	if node == nil {
+		return experimentalsys.ENOENT
+	}
+	if node.mode.IsDir() {
+		return experimentalsys.EISDIR
+	}
+	idx := parent.indexOf(base)
+	parent.children = append(parent.children[:idx], parent.children[idx+1:]...)
+	node.nlink--
+	return 0
+}
+
+// Link implements fsapi.FS.
+func (m *MemFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _, oldNode, errno := m.walk(oldPath, false)
+	if errno != 0 {
+		return errno
+	}
+	if oldNode == nil {
+		return experimentalsys.ENOENT
+	}
+	if oldNode.mode.IsDir() {
+		return experimentalsys.EPERM
+	}
+	newParent, newBase, newNode, errno := m.walk(newPath, false)
+	if errno != 0 {
+		return errno
+	}
+	if newNode != nil {
+		return experimentalsys.EEXIST
+	}
+	oldNode.nlink++
+	newParent.children = append(newParent.children, memDirent{name: newBase, inode: oldNode})
+	return 0
+}
+
+// Symlink implements fsapi.FS.
+func (m *MemFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent, base, node, errno := m.walk(linkName, false)
+	if errno != 0 {
+		return errno
+	}
+	if node != nil {
+		return experimentalsys.EEXIST
+	}
+	now := int64(0)
+	link := &memInode{mode: fs.ModeSymlink | 0o777, nlink: 1, symlink: oldPath, atim: now, mtim: now, ctim: now}
+	parent.children = append(parent.children, memDirent{name: base, inode: link})
+	return 0
+}
+
+// Readlink implements fsapi.FS.
+func (m *MemFS) Readlink(path string) (string, experimentalsys.Errno) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _, node, errno := m.walk(path, false)
+	if errno != 0 {
+		return "", errno
+	}
+	if node == nil {
+		return "", experimentalsys.ENOENT
+	}
+	if node.mode.Type() != fs.ModeSymlink {
+		return "", experimentalsys.EINVAL
+	}
+	return node.symlink, 0
+}
+
+// Utimens implements fsapi.FS, honoring UTIME_NOW and UTIME_OMIT the
+// same way a real `utimensat` would.
+func (m *MemFS) Utimens(path string, times *[2]syscall.Timespec, symlinkFollow bool) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _, node, errno := m.walk(path, symlinkFollow)
+	if errno != 0 {
+		return errno
+	}
+	if node == nil {
+		return experimentalsys.ENOENT
+	}
+
+	applyTime := func(cur *int64, ts syscall.Timespec, isSet bool) {
+		if !isSet {
+			return
+		}
+		switch ts.Nsec {
+		case utimeOmit:
+		case utimeNow:
+			*cur = 0
+		default:
+			*cur = int64(ts.Sec)*1e9 + int64(ts.Nsec)
+		}
+	}
+	if times == nil {
+		node.atim, node.mtim = 0, 0
+	} else {
+		applyTime(&node.atim, times[0], true)
+		applyTime(&node.mtim, times[1], true)
+	}
+	return 0
+}
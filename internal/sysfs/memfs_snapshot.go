@@ -0,0 +1,109 @@
+package sysfs
+
+import (
+	"encoding/json"
+	"io/fs"
+)
+
+// memFSSnapshot is the portable, JSON-encodable form of a MemFS tree.
+// Inodes are flattened into a slice and referenced by index so that
+// hardlinks (more than one dirent pointing at the same *memInode)
+// round-trip correctly instead of being duplicated.
+type memFSSnapshot struct {
+	Root   int                 `json:"root"`
+	Inodes []memInodeSnapshot  `json:"inodes"`
+}
+
+type memInodeSnapshot struct {
+	Mode    uint32              `json:"mode"`
+	Atim    int64               `json:"atim"`
+	Mtim    int64               `json:"mtim"`
+	Ctim    int64               `json:"ctim"`
+	Data    []byte              `json:"data,omitempty"`
+	Symlink string              `json:"symlink,omitempty"`
+	Entries []memDirentSnapshot `json:"entries,omitempty"`
+}
+
+type memDirentSnapshot struct {
+	Name  string `json:"name"`
+	Inode int    `json:"inode"`
+}
+
+// Snapshot serializes the entire tree into a portable format suitable
+// for checking into a repository as a test fixture, or for stashing and
+// later restoring the state of a MemFS between test cases.
+func (m *MemFS) Snapshot() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := map[*memInode]int{}
+	var snap memFSSnapshot
+
+	var visit func(n *memInode) int
+	visit = func(n *memInode) int {
+		if id, ok := ids[n]; ok {
+			return id
+		}
+		id := len(snap.Inodes)
+		ids[n] = id
+		snap.Inodes = append(snap.Inodes, memInodeSnapshot{}) // reserve the slot before recursing.
+
+		entries := make([]memDirentSnapshot, len(n.children))
+		for i, child := range n.children {
+			entries[i] = memDirentSnapshot{Name: child.name, Inode: visit(child.inode)}
+		}
+		snap.Inodes[id] = memInodeSnapshot{
+			Mode:    uint32(n.mode),
+			Atim:    n.atim,
+			Mtim:    n.mtim,
+			Ctim:    n.ctim,
+			Data:    n.data,
+			Symlink: n.symlink,
+			Entries: entries,
+		}
+		return id
+	}
+	snap.Root = visit(m.root)
+
+	return json.Marshal(snap)
+}
+
+// Restore replaces the tree with the one serialized by a prior
+// Snapshot call, discarding any in-memory state it currently holds.
+func (m *MemFS) Restore(data []byte) error {
+	var snap memFSSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+
+	inodes := make([]*memInode, len(snap.Inodes))
+	for i, is := range snap.Inodes {
+		inodes[i] = &memInode{
+			mode:    fs.FileMode(is.Mode),
+			atim:    is.Atim,
+			mtim:    is.Mtim,
+			ctim:    is.Ctim,
+			data:    is.Data,
+			symlink: is.Symlink,
+		}
+	}
+	for i, is := range snap.Inodes {
+		n := inodes[i]
+		for _, e := range is.Entries {
+			n.children = append(n.children, memDirent{name: e.Name, inode: inodes[e.Inode]})
+			inodes[e.Inode].nlink++
+		}
+	}
+	// The root and any inode with no remaining dirent (e.g. the root
+	// itself, which nothing points at) still need nlink >= 1.
+	for _, n := range inodes {
+		if n.nlink == 0 {
+			n.nlink = 1
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.root = inodes[snap.Root]
+	return nil
+}
@@ -0,0 +1,48 @@
+package sysfs
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestMemFSRenameSamePath(t *testing.T) {
+	m := NewMemFS()
+	f, errno := m.OpenFile("a", syscall.O_CREAT|syscall.O_WRONLY, 0o644)
+	if errno != 0 {
+		t.Fatalf("OpenFile: %v", errno)
+	}
+	if errno := f.Close(); errno != 0 {
+		t.Fatalf("Close: %v", errno)
+	}
+
+	if errno := m.Rename("a", "a"); errno != 0 {
+		t.Fatalf("Rename(a, a): %v", errno)
+	}
+	if _, errno := m.Stat("a"); errno != 0 {
+		t.Fatalf("Stat(a) after self-rename: %v", errno)
+	}
+}
+
+func TestMemFSRenameSameHardlink(t *testing.T) {
+	m := NewMemFS()
+	f, errno := m.OpenFile("a", syscall.O_CREAT|syscall.O_WRONLY, 0o644)
+	if errno != 0 {
+		t.Fatalf("OpenFile: %v", errno)
+	}
+	if errno := f.Close(); errno != 0 {
+		t.Fatalf("Close: %v", errno)
+	}
+	if errno := m.Link("a", "b"); errno != 0 {
+		t.Fatalf("Link: %v", errno)
+	}
+
+	if errno := m.Rename("a", "b"); errno != 0 {
+		t.Fatalf("Rename(a, b) of the same inode: %v", errno)
+	}
+	if _, errno := m.Stat("a"); errno != 0 {
+		t.Fatalf("Stat(a) after renaming onto its own hardlink: %v", errno)
+	}
+	if _, errno := m.Stat("b"); errno != 0 {
+		t.Fatalf("Stat(b) after renaming onto its own hardlink: %v", errno)
+	}
+}
@@ -0,0 +1,459 @@
+package sysfs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"syscall"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/fsapi"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// whiteoutPrefix marks a deleted path in the upper layer, following the
+// convention used by aufs/overlay2: a zero-byte regular file named
+// ".wh.<name>" next to where <name> used to be. A character device
+// would be more faithful to Linux's overlayfs, but wazero's FS
+// abstraction has no portable way to create one, and a zero-byte file
+// is enough to record "this name is gone" for every layer below it.
+const whiteoutPrefix = ".wh."
+
+// NewOverlayFS returns a fsapi.FS presenting a union view of `upper`
+// and `lowers`, in that order: a read resolves against upper first,
+// then each lower in turn, stopping at the first layer that has the
+// path. Every write, creation or rename materializes its target into
+// upper ("copy-up"), so the lower layers are never modified - mounting
+// an immutable fs.FS (via Adapt) as a lower layer alongside a writable
+// scratch directory as upper is a common use, matching how container
+// runtimes stack image layers.
+//
+// Deleting a path that exists in a lower layer doesn't remove it from
+// that layer (which may not even be writable); instead, a whiteout
+// marker is recorded in upper, which every read-side method checks
+// before falling through to the lower layers.
+//
+// # Notes
+//
+//   - Renaming a path that only exists in a lower layer copies it up to
+//     upper first, then renames within upper, leaving a whiteout behind
+//     at the old path.
+//   - Directory listings merge entries from every layer, preferring
+//     upper's entry when a name exists in more than one layer, and
+//     omitting whited-out names entirely.
+func NewOverlayFS(upper fsapi.FS, lowers ...fsapi.FS) fsapi.FS {
+	return &overlayFS{upper: upper, lowers: lowers}
+}
+
+type overlayFS struct {
+	fsapi.UnimplementedFS
+
+	upper  fsapi.FS
+	lowers []fsapi.FS
+}
+
+// layers returns every layer, upper first, in read-resolution order.
+func (o *overlayFS) layers() []fsapi.FS {
+	all := make([]fsapi.FS, 0, 1+len(o.lowers))
+	all = append(all, o.upper)
+	return append(all, o.lowers...)
+}
+
+func whiteoutPath(p string) string {
+	dir, base := path.Split(p)
+	return dir + whiteoutPrefix + base
+}
+
+// whitedOut reports whether `p` has been deleted: a whiteout marker for
+// it exists in the upper layer.
+func (o *overlayFS) whitedOut(p string) bool {
+	_, errno := o.upper.Lstat(whiteoutPath(p))
+	return errno == 0
+}
+
+func (o *overlayFS) clearWhiteout(p string) {
+	_ = o.upper.Unlink(whiteoutPath(p))
+}
+
+func (o *overlayFS) markWhiteout(p string) experimentalsys.Errno {
+	f, errno := o.upper.OpenFile(whiteoutPath(p), syscall.O_CREAT|syscall.O_TRUNC|syscall.O_WRONLY, 0o644)
+	if errno != 0 {
+		return errno
+	}
+	return f.Close()
+}
+
+// find returns the first layer (upper first, then lowers in order) that
+// has `path`, skipping lookups entirely once a whiteout is seen.
+func (o *overlayFS) find(path string) (fsapi.FS, experimentalsys.Errno) {
+	if o.whitedOut(path) {
+		return nil, experimentalsys.ENOENT
+	}
+	for _, l := range o.layers() {
+		if _, errno := l.Lstat(path); errno == 0 {
+			return l, 0
+		}
+	}
+	return nil, experimentalsys.ENOENT
+}
+
+// copyUp ensures `path` exists in the upper layer, copying its content
+// up from the first lower layer that has it if necessary. It is a
+// no-op if `path` already exists in upper.
+func (o *overlayFS) copyUp(path string) experimentalsys.Errno {
+	if _, errno := o.upper.Lstat(path); errno == 0 {
+		return 0
+	}
+
+	// o.find, not an ad-hoc lower-layer loop: it already checks
+	// whiteouts, so a path Unlink/Rmdir has recorded a whiteout for
+	// stays gone instead of being resurrected from a lower layer here.
+	src, errno := o.find(path)
+	if errno != 0 {
+		return errno
+	}
+
+	// The parent directory may itself exist only in a lower layer: copy
+	// it up first, or creating `path` in upper fails with ENOENT.
+	if i := strings.LastIndexByte(path, '/'); i > 0 {
+		if errno := o.copyUp(path[:i]); errno != 0 {
+			return errno
+		}
+	}
+
+	st, errno := src.Lstat(path)
+	if errno != 0 {
+		return errno
+	}
+
+	if st.Mode.Type() == fs.ModeSymlink {
+		target, errno := src.Readlink(path)
+		if errno != 0 {
+			return errno
+		}
+		return o.upper.Symlink(target, path)
+	}
+	if st.Mode.IsDir() {
+		return o.upper.Mkdir(path, st.Mode.Perm())
+	}
+
+	in, errno := src.OpenFile(path, syscall.O_RDONLY, 0)
+	if errno != 0 {
+		return errno
+	}
+	defer in.Close()
+
+	out, errno := o.upper.OpenFile(path, syscall.O_CREAT|syscall.O_TRUNC|syscall.O_WRONLY, st.Mode.Perm())
+	if errno != 0 {
+		return errno
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out.(io.Writer), in.(io.Reader)); err != nil {
+		return experimentalsys.UnwrapOSError(err)
+	}
+	return 0
+}
+
+// OpenFile implements fsapi.FS.
+func (o *overlayFS) OpenFile(p string, flag int, perm fs.FileMode) (fsapi.File, experimentalsys.Errno) {
+	readOnly := flag&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_CREAT) == 0
+
+	if readOnly {
+		if o.whitedOut(p) {
+			return nil, experimentalsys.ENOENT
+		}
+		for _, l := range o.layers() {
+			if f, errno := l.OpenFile(p, flag, perm); errno == 0 {
+				if isDir, _ := f.IsDir(); isDir {
+					return o.openDir(p, f), 0
+				}
+				return f, 0
+			} else if errno != experimentalsys.ENOENT {
+				return nil, errno
+			}
+		}
+		return nil, experimentalsys.ENOENT
+	}
+
+	// Any write, append or create requires the target to live in upper.
+	if _, errno := o.upper.Lstat(p); errno != 0 {
+		// Whether this is a true fresh create has to be decided by
+		// find, which checks whiteouts and every lower layer: checking
+		// only o.upper.Lstat's errno (as this used to) can't tell "p
+		// doesn't exist anywhere" apart from "p exists in a lower
+		// layer", and wrongly skipped copyUp for the latter, silently
+		// discarding the lower layer's content under O_CREAT.
+		if _, ferrno := o.find(p); ferrno == 0 {
+			if flag&(syscall.O_CREAT|syscall.O_EXCL) == syscall.O_CREAT|syscall.O_EXCL {
+				return nil, experimentalsys.EEXIST
+			}
+			if errno := o.copyUp(p); errno != 0 {
+				return nil, errno
+			}
+		} else if flag&syscall.O_CREAT == 0 {
+			return nil, ferrno
+		}
+		// Otherwise, p doesn't exist in any layer (or was whited out):
+		// a fresh create in upper, no copy-up needed.
+	}
+
+	f, errno := o.upper.OpenFile(p, flag, perm)
+	if errno != 0 {
+		return nil, errno
+	}
+	o.clearWhiteout(p)
+	return f, 0
+}
+
+// openDir wraps `first` - the handle OpenFile already opened against
+// the first layer that has `p` - so that Readdir on it returns the
+// union of every layer's entries for `p` instead of just that one
+// layer's, per the directory merging described on NewOverlayFS.
+func (o *overlayFS) openDir(p string, first fsapi.File) fsapi.File {
+	return &overlayDirFile{File: first, o: o, path: p}
+}
+
+// overlayDirFile merges Readdir across every layer on first use, upper
+// winning when a name exists in more than one layer, and serves
+// subsequent calls (with whatever `n` WASI's fd_readdir asks for) out
+// of that merged, stable listing.
+type overlayDirFile struct {
+	fsapi.File
+
+	o    *overlayFS
+	path string
+
+	merged []experimentalsys.Dirent
+	offset int
+}
+
+// Readdir implements fsapi.File.
+func (d *overlayDirFile) Readdir(n int) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	if d.merged == nil {
+		merged, errno := d.o.mergedReaddir(d.path)
+		if errno != 0 {
+			return nil, errno
+		}
+		if merged == nil {
+			merged = []experimentalsys.Dirent{}
+		}
+		d.merged = merged
+	}
+
+	start := d.offset
+	if start > len(d.merged) {
+		start = len(d.merged)
+	}
+	end := len(d.merged)
+	if n >= 0 && start+n < end {
+		end = start + n
+	}
+	d.offset = end
+	return append([]experimentalsys.Dirent(nil), d.merged[start:end]...), 0
+}
+
+// mergedReaddir lists every layer's directory entries for `p`, upper
+// first: a name already seen in an earlier layer is skipped, a whiteout
+// marker is never itself listed, and a name it whites out in a lower
+// layer is omitted entirely.
+func (o *overlayFS) mergedReaddir(p string) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	seen := make(map[string]bool)
+	var merged []experimentalsys.Dirent
+
+	for _, l := range o.layers() {
+		f, errno := l.OpenFile(p, syscall.O_RDONLY, 0)
+		if errno == experimentalsys.ENOENT {
+			continue
+		} else if errno != 0 {
+			return nil, errno
+		}
+		entries, errno := f.Readdir(-1)
+		_ = f.Close()
+		if errno == experimentalsys.ENOTDIR {
+			// This layer has a non-directory at `p`: upper (or an
+			// earlier lower layer) already shadows it entirely.
+			continue
+		} else if errno != 0 {
+			return nil, errno
+		}
+
+		for _, e := range entries {
+			if seen[e.Name] {
+				continue
+			}
+			seen[e.Name] = true
+			if strings.HasPrefix(e.Name, whiteoutPrefix) || o.whitedOut(path.Join(p, e.Name)) {
+				continue
+			}
+			merged = append(merged, e)
+		}
+	}
+	return merged, 0
+}
+
+// Lstat implements fsapi.FS.
+func (o *overlayFS) Lstat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	l, errno := o.find(path)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return l.Lstat(path)
+}
+
+// Stat implements fsapi.FS.
+func (o *overlayFS) Stat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	l, errno := o.find(path)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return l.Stat(path)
+}
+
+// Statfs implements fsapi.FS, reporting the upper layer's statistics,
+// since that's where all growth in this overlay happens.
+func (o *overlayFS) Statfs(path string) (sys.StatFs_t, experimentalsys.Errno) {
+	return o.upper.Statfs(path)
+}
+
+// Mkdir implements fsapi.FS.
+func (o *overlayFS) Mkdir(path string, perm fs.FileMode) experimentalsys.Errno {
+	if _, errno := o.find(path); errno == 0 {
+		return experimentalsys.EEXIST
+	}
+	errno := o.upper.Mkdir(path, perm)
+	if errno == 0 {
+		o.clearWhiteout(path)
+	}
+	return errno
+}
+
+// Chmod implements fsapi.FS.
+func (o *overlayFS) Chmod(path string, perm fs.FileMode) experimentalsys.Errno {
+	if errno := o.copyUp(path); errno != 0 {
+		return errno
+	}
+	return o.upper.Chmod(path, perm)
+}
+
+// Rename implements fsapi.FS.
+//
+// If `from` only exists in a lower layer, it is copied up to upper
+// first, then renamed within upper, and a whiteout is left behind at
+// `from` so the lower layer's copy stops being visible.
+func (o *overlayFS) Rename(from, to string) experimentalsys.Errno {
+	// o.find, not o.upper.Lstat alone: a whiteout recorded for `from`
+	// must make Rename see it as gone, not fall through to a lower
+	// layer's still-physically-present copy.
+	fromLayer, errno := o.find(from)
+	if errno != 0 {
+		return errno
+	}
+	fromOnlyInLower := fromLayer != o.upper
+
+	if fromOnlyInLower {
+		if errno := o.copyUp(from); errno != 0 {
+			return errno
+		}
+	}
+	if errno := o.upper.Rename(from, to); errno != 0 {
+		return errno
+	}
+	if fromOnlyInLower {
+		if errno := o.markWhiteout(from); errno != 0 {
+			return errno
+		}
+	}
+	o.clearWhiteout(to)
+	return 0
+}
+
+// Rmdir implements fsapi.FS.
+func (o *overlayFS) Rmdir(path string) experimentalsys.Errno {
+	return o.remove(path, true)
+}
+
+// Unlink implements fsapi.FS.
+func (o *overlayFS) Unlink(path string) experimentalsys.Errno {
+	return o.remove(path, false)
+}
+
+func (o *overlayFS) remove(path string, dir bool) experimentalsys.Errno {
+	l, errno := o.find(path)
+	if errno != 0 {
+		return errno
+	}
+
+	if dir {
+		st, errno := l.Lstat(path)
+		if errno != 0 {
+			return errno
+		}
+		if !st.Mode.IsDir() {
+			return experimentalsys.ENOTDIR
+		}
+		// The merged (union) listing, not just whichever single layer
+		// l happens to be: a directory empty in upper but still
+		// populated by a lower layer isn't actually empty from the
+		// guest's point of view, and must not be removable via a bare
+		// whiteout.
+		entries, errno := o.mergedReaddir(path)
+		if errno != 0 {
+			return errno
+		}
+		if len(entries) > 0 {
+			return experimentalsys.ENOTEMPTY
+		}
+	}
+
+	if l == o.upper {
+		if dir {
+			errno = o.upper.Rmdir(path)
+		} else {
+			errno = o.upper.Unlink(path)
+		}
+		if errno != 0 {
+			return errno
+		}
+	}
+	return o.markWhiteout(path)
+}
+
+// Link implements fsapi.FS.
+func (o *overlayFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	if errno := o.copyUp(oldPath); errno != 0 {
+		return errno
+	}
+	errno := o.upper.Link(oldPath, newPath)
+	if errno == 0 {
+		o.clearWhiteout(newPath)
+	}
+	return errno
+}
+
+// Symlink implements fsapi.FS.
+func (o *overlayFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	errno := o.upper.Symlink(oldPath, linkName)
+	if errno == 0 {
+		o.clearWhiteout(linkName)
+	}
+	return errno
+}
+
+// Readlink implements fsapi.FS.
+func (o *overlayFS) Readlink(path string) (string, experimentalsys.Errno) {
+	l, errno := o.find(path)
+	if errno != 0 {
+		return "", errno
+	}
+	return l.Readlink(path)
+}
+
+// Utimens implements fsapi.FS.
+func (o *overlayFS) Utimens(path string, times *[2]syscall.Timespec, symlinkFollow bool) experimentalsys.Errno {
+	if errno := o.copyUp(path); errno != 0 {
+		return errno
+	}
+	return o.upper.Utimens(path, times, symlinkFollow)
+}
@@ -0,0 +1,121 @@
+package sysfs
+
+import (
+	"syscall"
+	"testing"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+func writeFile(t *testing.T, fsys *MemFS, path, content string) {
+	t.Helper()
+	f, errno := fsys.OpenFile(path, syscall.O_CREAT|syscall.O_TRUNC|syscall.O_WRONLY, 0o644)
+	if errno != 0 {
+		t.Fatalf("OpenFile(%s): %v", path, errno)
+	}
+	if _, errno := f.Write([]byte(content)); errno != 0 {
+		t.Fatalf("Write(%s): %v", path, errno)
+	}
+	if errno := f.Close(); errno != 0 {
+		t.Fatalf("Close(%s): %v", path, errno)
+	}
+}
+
+func TestOverlayCopyUpRespectsWhiteout(t *testing.T) {
+	lower := NewMemFS()
+	writeFile(t, lower, "a", "lower content")
+
+	upper := NewMemFS()
+	o := NewOverlayFS(upper, lower)
+
+	if errno := o.Unlink("a"); errno != 0 {
+		t.Fatalf("Unlink(a): %v", errno)
+	}
+	if _, errno := o.Stat("a"); errno != experimentalsys.ENOENT {
+		t.Fatalf("Stat(a) after Unlink: got %v, want ENOENT", errno)
+	}
+
+	// Chmod, Utimens and Link all go through copyUp; Chmod is checked here:
+	// it must not resurrect the lower layer's whited-out "a".
+	if errno := o.Chmod("a", 0o600); errno != experimentalsys.ENOENT {
+		t.Fatalf("Chmod(a) after Unlink: got %v, want ENOENT", errno)
+	}
+	if _, errno := o.Stat("a"); errno != experimentalsys.ENOENT {
+		t.Fatalf("Stat(a) after Chmod attempt: got %v, want ENOENT", errno)
+	}
+}
+
+func TestOverlayRenameRespectsWhiteout(t *testing.T) {
+	lower := NewMemFS()
+	writeFile(t, lower, "a", "lower content")
+
+	upper := NewMemFS()
+	o := NewOverlayFS(upper, lower)
+
+	if errno := o.Unlink("a"); errno != 0 {
+		t.Fatalf("Unlink(a): %v", errno)
+	}
+	if errno := o.Rename("a", "b"); errno != experimentalsys.ENOENT {
+		t.Fatalf("Rename(a, b) after Unlink: got %v, want ENOENT", errno)
+	}
+}
+
+func TestOverlayRmdirNonEmptyAcrossLayers(t *testing.T) {
+	lower := NewMemFS()
+	if errno := lower.Mkdir("d", 0o755); errno != 0 {
+		t.Fatalf("lower.Mkdir(d): %v", errno)
+	}
+	writeFile(t, lower, "d/x", "content")
+
+	upper := NewMemFS()
+	if errno := upper.Mkdir("d", 0o755); errno != 0 {
+		t.Fatalf("upper.Mkdir(d): %v", errno)
+	}
+	o := NewOverlayFS(upper, lower)
+
+	// "d" is empty in upper, but "d/x" still exists in lower: Rmdir must
+	// see the merged listing, not just upper's, and refuse.
+	if errno := o.Rmdir("d"); errno != experimentalsys.ENOTEMPTY {
+		t.Fatalf("Rmdir(d): got %v, want ENOTEMPTY", errno)
+	}
+}
+
+func TestOverlayReaddirMergesLayers(t *testing.T) {
+	lower := NewMemFS()
+	if errno := lower.Mkdir("d", 0o755); errno != 0 {
+		t.Fatalf("lower.Mkdir(d): %v", errno)
+	}
+	writeFile(t, lower, "d/only-lower", "x")
+	writeFile(t, lower, "d/both", "lower version")
+
+	upper := NewMemFS()
+	if errno := upper.Mkdir("d", 0o755); errno != 0 {
+		t.Fatalf("upper.Mkdir(d): %v", errno)
+	}
+	writeFile(t, upper, "d/only-upper", "x")
+	writeFile(t, upper, "d/both", "upper version")
+
+	o := NewOverlayFS(upper, lower)
+	f, errno := o.OpenFile("d", syscall.O_RDONLY, 0)
+	if errno != 0 {
+		t.Fatalf("OpenFile(d): %v", errno)
+	}
+	defer f.Close()
+
+	entries, errno := f.Readdir(-1)
+	if errno != 0 {
+		t.Fatalf("Readdir(d): %v", errno)
+	}
+	names := map[string]bool{}
+	for _, e := range entries {
+		names[e.Name] = true
+	}
+	for _, want := range []string{"only-lower", "only-upper", "both"} {
+		if !names[want] {
+			t.Errorf("Readdir(d) missing entry %q, got %v", want, names)
+		}
+	}
+	if len(entries) != 3 {
+		t.Errorf("Readdir(d) = %d entries, want 3 (no duplicate for \"both\")", len(entries))
+	}
+}
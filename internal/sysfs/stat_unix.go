@@ -0,0 +1,54 @@
+//go:build linux || darwin
+
+package sysfs
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// statFromUnix converts a unix.Stat_t, as returned by unix.Fstatat, into
+// the WASI-oriented sys.Stat_t.
+func statFromUnix(st unix.Stat_t) sys.Stat_t {
+	return sys.Stat_t{
+		Dev:   uint64(st.Dev),
+		Ino:   st.Ino,
+		Mode:  fileModeFromUnix(uint32(st.Mode)),
+		Nlink: uint64(st.Nlink),
+		Size:  st.Size,
+		Mtim:  int64(st.Mtim.Sec)*1e9 + int64(st.Mtim.Nsec),
+		Ctim:  int64(st.Ctim.Sec)*1e9 + int64(st.Ctim.Nsec),
+		Atim:  int64(st.Atim.Sec)*1e9 + int64(st.Atim.Nsec),
+	}
+}
+
+// fileModeFromUnix converts a POSIX st_mode into the equivalent
+// fs.FileMode, the same way memfs.go builds a memInode's mode directly as
+// fs.ModeDir/fs.ModeSymlink/etc: POSIX's S_IFDIR/S_IFLNK/S_IFREG type
+// bits don't occupy the same positions as fs.FileMode's, so a raw
+// fs.FileMode(st.Mode) cast leaves IsDir/Type reporting nonsense for
+// every caller that checks them - including overlay.go, which relies on
+// exactly that to decide whether a layer's entry is a directory or
+// symlink.
+func fileModeFromUnix(mode uint32) fs.FileMode {
+	perm := fs.FileMode(mode & 0o777)
+	switch mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		return fs.ModeDir | perm
+	case unix.S_IFLNK:
+		return fs.ModeSymlink | perm
+	case unix.S_IFIFO:
+		return fs.ModeNamedPipe | perm
+	case unix.S_IFSOCK:
+		return fs.ModeSocket | perm
+	case unix.S_IFBLK:
+		return fs.ModeDevice | perm
+	case unix.S_IFCHR:
+		return fs.ModeDevice | fs.ModeCharDevice | perm
+	default: // unix.S_IFREG, or an unrecognized type.
+		return perm
+	}
+}
@@ -0,0 +1,31 @@
+//go:build linux || darwin
+
+package sysfs
+
+import (
+	"io/fs"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestFileModeFromUnix(t *testing.T) {
+	tests := []struct {
+		name string
+		mode uint32
+		want fs.FileMode
+	}{
+		{"regular file", unix.S_IFREG | 0o644, 0o644},
+		{"directory", unix.S_IFDIR | 0o755, fs.ModeDir | 0o755},
+		{"symlink", unix.S_IFLNK | 0o777, fs.ModeSymlink | 0o777},
+		{"fifo", unix.S_IFIFO | 0o600, fs.ModeNamedPipe | 0o600},
+		{"socket", unix.S_IFSOCK | 0o600, fs.ModeSocket | 0o600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileModeFromUnix(tt.mode); got != tt.want {
+				t.Errorf("fileModeFromUnix(%#o) = %#o, want %#o", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package sysfs
+
+import (
+	"golang.org/x/sys/unix"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// statfsPath implements sys.StatFs_t on Darwin via syscall.Statfs.
+func statfsPath(path string) (sys.StatFs_t, experimentalsys.Errno) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return sys.StatFs_t{}, experimentalsys.UnwrapOSError(err)
+	}
+	return statFsFromUnix(st), 0
+}
+
+// statFsFromUnix converts a Darwin unix.Statfs_t, as returned by
+// unix.Fstatfs, into the WASI-oriented sys.StatFs_t.
+func statFsFromUnix(st unix.Statfs_t) sys.StatFs_t {
+	return sys.StatFs_t{
+		Bsize:  uint64(st.Bsize),
+		Blocks: st.Blocks,
+		Bfree:  st.Bfree,
+		Bavail: st.Bavail,
+		Files:  st.Files,
+		Ffree:  st.Ffree,
+		Fstype: int8sToString(st.Fstypename[:]),
+		Flags:  uint64(st.Flags),
+	}
+}
+
+// int8sToString converts a NUL-terminated [N]int8, as used by Darwin's C
+// structs, into a Go string.
+func int8sToString(b []int8) string {
+	n := 0
+	for n < len(b) && b[n] != 0 {
+		n++
+	}
+	buf := make([]byte, n)
+	for i := 0; i < n; i++ {
+		buf[i] = byte(b[i])
+	}
+	return string(buf)
+}
@@ -0,0 +1,25 @@
+package sysfs
+
+import (
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// statfsPath is used where there's no real volume to ask, namely
+// GOOS=js. Rather than ENOSYS, which would make every guest `df` or
+// quota check fail outright, this returns large, self-consistent
+// synthetic values: plenty of free space and plenty of free inodes.
+func statfsPath(path string) (sys.StatFs_t, experimentalsys.Errno) {
+	const bsize = 4096
+	const blocks = 1 << 20 // 4GiB worth of synthetic space.
+	return sys.StatFs_t{
+		Bsize:  bsize,
+		Blocks: blocks,
+		Bfree:  blocks,
+		Bavail: blocks,
+		Files:  1 << 20,
+		Ffree:  1 << 20,
+		Fstype: "wasmfs",
+		Flags:  0,
+	}, 0
+}
@@ -0,0 +1,52 @@
+package sysfs
+
+import (
+	"golang.org/x/sys/unix"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// statfsPath implements sys.StatFs_t on Linux via syscall.Statfs.
+func statfsPath(path string) (sys.StatFs_t, experimentalsys.Errno) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return sys.StatFs_t{}, experimentalsys.UnwrapOSError(err)
+	}
+	return statFsFromUnix(st), 0
+}
+
+// statFsFromUnix converts a Linux unix.Statfs_t, as returned by
+// unix.Fstatfs, into the WASI-oriented sys.StatFs_t.
+func statFsFromUnix(st unix.Statfs_t) sys.StatFs_t {
+	return sys.StatFs_t{
+		Bsize:  uint64(st.Bsize),
+		Blocks: st.Blocks,
+		Bfree:  st.Bfree,
+		Bavail: st.Bavail,
+		Files:  st.Files,
+		Ffree:  st.Ffree,
+		Fstype: fsTypeName(st.Type),
+		Flags:  uint64(st.Flags),
+	}
+}
+
+// fsTypeName maps the f_type magic number Linux's statfs(2) returns into
+// the handful of names guests most commonly care about. Unknown magic
+// numbers are reported as an empty string rather than guessed at.
+func fsTypeName(magic int64) string {
+	switch magic {
+	case unix.EXT4_SUPER_MAGIC:
+		return "ext4"
+	case unix.TMPFS_MAGIC:
+		return "tmpfs"
+	case unix.OVERLAYFS_SUPER_MAGIC:
+		return "overlayfs"
+	case unix.NFS_SUPER_MAGIC:
+		return "nfs"
+	case unix.PROC_SUPER_MAGIC:
+		return "proc"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,62 @@
+package sysfs
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// statfsPath implements sys.StatFs_t for Windows, where there's no
+// single syscall equivalent to statfs(2): free/total space comes from
+// GetDiskFreeSpaceExW, and the volume's filesystem name and flags come
+// from GetVolumeInformationW. The block size is always reported as 1.
+func statfsPath(path string) (sys.StatFs_t, experimentalsys.Errno) {
+	root, err := windows.UTF16PtrFromString(volumeRoot(path))
+	if err != nil {
+		return sys.StatFs_t{}, experimentalsys.EINVAL
+	}
+
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	if err := windows.GetDiskFreeSpaceEx(root, &freeBytesAvailable, &totalBytes, &totalFreeBytes); err != nil {
+		return sys.StatFs_t{}, experimentalsys.UnwrapOSError(err)
+	}
+
+	var volumeNameBuf, fsNameBuf [windows.MAX_PATH + 1]uint16
+	var fsFlags, serialNumber, maxComponentLen uint32
+	if err := windows.GetVolumeInformation(
+		root,
+		&volumeNameBuf[0], uint32(len(volumeNameBuf)),
+		&serialNumber,
+		&maxComponentLen,
+		&fsFlags,
+		&fsNameBuf[0], uint32(len(fsNameBuf)),
+	); err != nil {
+		return sys.StatFs_t{}, experimentalsys.UnwrapOSError(err)
+	}
+
+	// Windows doesn't expose a block size directly: report sectors of 1,
+	// so that Blocks/Bfree/Bavail are exact byte counts.
+	const bsize = 1
+	return sys.StatFs_t{
+		Bsize:  bsize,
+		Blocks: totalBytes / bsize,
+		Bfree:  totalFreeBytes / bsize,
+		Bavail: freeBytesAvailable / bsize,
+		Files:  0, // Windows has no fixed inode count to report.
+		Ffree:  0,
+		Fstype: windows.UTF16ToString(fsNameBuf[:]),
+		Flags:  uint64(fsFlags),
+	}, 0
+}
+
+// volumeRoot returns the volume root (e.g. `C:\`) containing `path`, as
+// required by GetDiskFreeSpaceEx and GetVolumeInformation.
+func volumeRoot(path string) string {
+	if vol := filepath.VolumeName(path); vol != "" {
+		return vol + `\`
+	}
+	return `\`
+}
@@ -0,0 +1,41 @@
+package sys
+
+// StatFs_t is the WASI-oriented equivalent of syscall.Statfs_t, returned
+// by fsapi.FS's Statfs. All fields are zero-extended to their largest
+// common width so that 32-bit and 64-bit platforms agree on layout.
+//
+// Note: this is used for WASI's `path_filestat_get`-adjacent statvfs
+// support, as well as for guests implementing `df` or sizing buffered
+// writes based on free space.
+type StatFs_t struct {
+	// Bsize is the fundamental file system block size, in bytes.
+	Bsize uint64
+
+	// Blocks is the total number of Bsize blocks in the file system.
+	Blocks uint64
+
+	// Bfree is the number of free blocks in the file system.
+	Bfree uint64
+
+	// Bavail is the number of blocks free for use by unprivileged
+	// callers.
+	Bavail uint64
+
+	// Files is the total number of file serial numbers (inodes).
+	Files uint64
+
+	// Ffree is the number of free file serial numbers (inodes).
+	Ffree uint64
+
+	// Fstype identifies the file system type, for example "ext4" or
+	// "ntfs". It is empty when the underlying implementation cannot
+	// determine it.
+	Fstype string
+
+	// Flags holds the platform's own mount flags, passed through
+	// unconverted: statfs f_flags (ST_* bits) on Linux, statfs f_flags
+	// (MNT_* bits) on Darwin, and the volume's file system flags from
+	// GetVolumeInformationW on Windows. It is zero where there's no real
+	// volume.
+	Flags uint64
+}